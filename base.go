@@ -0,0 +1,34 @@
+package httpauth
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// baseHandler holds what every authentication scheme in this package
+// needs: the scheme name that appears in the WWW-Authenticate header,
+// the realm advertised to clients, and the handler to call once a
+// request authenticates. Basic's handler and Digest's digestHandler
+// both embed it instead of each declaring their own copies.
+type baseHandler struct {
+	method  string
+	realm   string
+	success http.Handler
+}
+
+// writeChallenge writes a WWW-Authenticate (or other named header)
+// challenge combining the realm with any scheme-specific attributes,
+// e.g. Digest's qop/nonce/opaque, and sets the response code.
+func (b *baseHandler) writeChallenge(w http.ResponseWriter, header string, code int, extraAttrs ...string) {
+	writeChallenge(w, header, code, b.realm, b.method, extraAttrs...)
+}
+
+// writeChallenge is the one place every scheme in this package builds a
+// challenge header from, whether it has a baseHandler to hand (Digest,
+// Basic's own handler) or only a realm/method pair (the CredentialSource
+// implementations, which challenge on Basic's behalf).
+func writeChallenge(w http.ResponseWriter, header string, code int, realm, method string, extraAttrs ...string) {
+	attrs := append([]string{fmt.Sprintf(`realm="%s"`, realm)}, extraAttrs...)
+	w.Header().Add(header, challengeHeader(method, attrs...))
+	w.WriteHeader(code)
+}
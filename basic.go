@@ -0,0 +1,117 @@
+// Package httpauth provides http.Handlers that handle standard HTTP
+// authentication methods.
+package httpauth
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// BasicOption configures a Basic handler.
+type BasicOption func(*handler)
+
+// WithCredentialSource adds src as a place to look for credentials, in
+// addition to (or, if this is the first call, instead of) the default
+// of reading the standard Authorization header. Sources are tried in
+// the order given; the first source that yields any candidate is the
+// one used to report failure if none of its candidates authenticate.
+func WithCredentialSource(src CredentialSource) BasicOption {
+	return func(h *handler) {
+		if h.defaultSource {
+			h.sources = nil
+			h.defaultSource = false
+		}
+		h.sources = append(h.sources, src)
+	}
+}
+
+// WithCharset sets the charset parameter advertised in the
+// WWW-Authenticate challenge, per RFC 7617. The only value the RFC
+// defines is "UTF-8", which also causes decoded credentials to be
+// normalised to Unicode NFC before authFunc is called, so that
+// passwords typed on different clients compare equal byte-for-byte.
+func WithCharset(charset string) BasicOption {
+	return func(h *handler) { h.charset = charset }
+}
+
+// Basic creates an http.Handler that perfoms basic authentication on
+// incoming HTTP request. The given realm is passed to browsers or
+// clients who attempt to connect without authenticating. The given
+// authFunc is used to authenticate a client with the parameters
+// passed being the user name and password. If authentication
+// succeeds, the onSuccess handler is called with the request. If
+// authentication fails, the client receives a 401 unauthorized.
+//
+// By default credentials are read from every value of the Authorization
+// header, so that clients or proxies presenting more than one candidate
+// in a single request are all considered until one authenticates. Pass
+// WithCredentialSource to read from somewhere else instead, such as a
+// cookie or a query parameter.
+func Basic(realm string, onSuccess http.Handler, authFunc func(string, string) bool, opts ...BasicOption) http.Handler {
+	h := &handler{
+		baseHandler:   baseHandler{method: "Basic", realm: realm, success: onSuccess},
+		auth:          authFunc,
+		sources:       []CredentialSource{HeaderSource()},
+		defaultSource: true,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+type handler struct {
+	baseHandler
+	auth    func(string, string) bool
+	charset string
+
+	sources       []CredentialSource
+	defaultSource bool
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, src := range h.sources {
+		candidates := src.Extract(r, h.method)
+		if len(candidates) == 0 {
+			continue
+		}
+		for _, candidate := range candidates {
+			user, pass, ok := decodeBasicCredential(candidate, h.charset)
+			if !ok {
+				continue
+			}
+			if h.auth(user, pass) {
+				h.success.ServeHTTP(w, r)
+				return
+			}
+		}
+		src.Fail(w, h.realm, h.method, h.charset)
+		return
+	}
+
+	// None of the sources found anything to even try; challenge via
+	// whichever source was configured first.
+	h.sources[0].Fail(w, h.realm, h.method, h.charset)
+}
+
+// decodeBasicCredential decodes a base64-encoded "user:password" value,
+// as carried by the Basic scheme, into its two parts. Unlike a naive
+// split on ":", it allows the password to contain colons, matching the
+// behaviour of net/http's Request.BasicAuth. If charset is "UTF-8", the
+// decoded user and password are normalised to Unicode NFC per RFC 7617.
+func decodeBasicCredential(encoded string, charset string) (user, pass string, ok bool) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", false
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	user, pass = parts[0], parts[1]
+	if strings.EqualFold(charset, "UTF-8") {
+		user, pass = normalizeNFC(user), normalizeNFC(pass)
+	}
+	return user, pass, true
+}
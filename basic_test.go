@@ -64,11 +64,12 @@ func TestBasic(t *testing.T) {
 			code:   http.StatusUnauthorized,
 			fail:   true,
 		},
-		// Header with more than two fields in the base64
+		// Header with more than one colon in the base64: the password,
+		// not the split, wins; this is a valid "test" / "nothing/123:abs".
 		{
 			header: "Basic dGVzdDpub3RoaW5nLzEyMzphYnM=",
-			user:   "",
-			pass:   "",
+			user:   "test",
+			pass:   "nothing/123:abs",
 			code:   http.StatusUnauthorized,
 			fail:   true,
 		},
@@ -135,3 +136,74 @@ func TestBasic(t *testing.T) {
 		}
 	}
 }
+
+func TestBasicMultipleAuthorizationHeaders(t *testing.T) {
+	handler := Basic("test-realm",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("test-good"))
+		}), func(u, p string) bool {
+			return u == "test" && p == "nothing"
+		})
+
+	req, err := http.NewRequest("GET", "http://example.com/foo", nil)
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	// A reverse proxy might concatenate several candidate identities
+	// into one request; the first bad one should not prevent a later
+	// good one from succeeding.
+	req.Header.Add("Authorization", "Basic d3Jvbmc6Y3JlZHM=") // wrong:creds
+	req.Header.Add("Authorization", "Basic dGVzdDpub3RoaW5n")  // test:nothing
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Response Code = %v, expected %v", w.Code, http.StatusOK)
+	}
+	if w.Body.String() != "test-good" {
+		t.Errorf("response body = %v, expected test-good", w.Body.String())
+	}
+}
+
+func TestBasicCredentialSources(t *testing.T) {
+	onSuccess := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("test-good"))
+	})
+	authFunc := func(u, p string) bool { return u == "test" && p == "nothing" }
+	encoded := "dGVzdDpub3RoaW5n" // test:nothing
+
+	t.Run("cookie", func(t *testing.T) {
+		handler := Basic("test-realm", onSuccess, authFunc, WithCredentialSource(CookieSource("auth")))
+		req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+		req.AddCookie(&http.Cookie{Name: "auth", Value: encoded})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Response Code = %v, expected %v", w.Code, http.StatusOK)
+		}
+	})
+
+	t.Run("query", func(t *testing.T) {
+		handler := Basic("test-realm", onSuccess, authFunc, WithCredentialSource(QuerySource("auth")))
+		req, _ := http.NewRequest("GET", "http://example.com/foo?auth="+encoded, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Response Code = %v, expected %v", w.Code, http.StatusOK)
+		}
+	})
+
+	t.Run("proxy header", func(t *testing.T) {
+		handler := Basic("test-realm", onSuccess, authFunc, WithCredentialSource(ProxyHeaderSource()))
+		req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusProxyAuthRequired {
+			t.Fatalf("Response Code = %v, expected %v", w.Code, http.StatusProxyAuthRequired)
+		}
+		expect := `Basic realm="test-realm"`
+		if got := w.HeaderMap.Get("Proxy-Authenticate"); got != expect {
+			t.Errorf("Proxy-Authenticate = %v, expected %v", got, expect)
+		}
+	})
+}
@@ -0,0 +1,15 @@
+package httpauth
+
+import "strings"
+
+// challengeHeader builds a WWW-Authenticate (or Proxy-Authenticate) style
+// challenge value, e.g. `Digest realm="foo", qop="auth"`, from a scheme
+// name and a list of already-formatted "key=value" attributes. It is
+// the one place Basic and Digest share for assembling a challenge, so
+// that both present attributes the same way.
+func challengeHeader(scheme string, attrs ...string) string {
+	if len(attrs) == 0 {
+		return scheme
+	}
+	return scheme + " " + strings.Join(attrs, ", ")
+}
@@ -0,0 +1,74 @@
+package httpauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBasicCharset(t *testing.T) {
+	var gotUser, gotPass string
+	handler := Basic("test-realm",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("test-good"))
+		}), func(u, p string) bool {
+			gotUser, gotPass = u, p
+			return true
+		}, WithCharset("UTF-8"))
+
+	// "adélaïde" encoded as NFD (combining diacriticals) rather than the
+	// precomposed NFC form; RFC 7617 charset=UTF-8 should normalise it.
+	const nfdUser = "adélaïde"
+	const nfcUser = "adélaïde"
+
+	req, err := http.NewRequest("GET", "http://example.com/foo", nil)
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	req.SetBasicAuth(nfdUser, "secret")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if gotUser != nfcUser {
+		t.Errorf("User = %q, expected NFC-normalised %q", gotUser, nfcUser)
+	}
+	if gotPass != "secret" {
+		t.Errorf("Pass = %q, expected %q", gotPass, "secret")
+	}
+
+	// The challenge for an unauthenticated request should advertise the
+	// charset.
+	unauth := Basic("test-realm", nil, func(string, string) bool { return false }, WithCharset("UTF-8"))
+	req2, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	w2 := httptest.NewRecorder()
+	unauth.ServeHTTP(w2, req2)
+	expect := `Basic realm="test-realm", charset="UTF-8"`
+	if got := w2.HeaderMap.Get("WWW-Authenticate"); got != expect {
+		t.Errorf("WWW-Authenticate = %v, expected %v", got, expect)
+	}
+}
+
+func TestAuthFuncConstantTime(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword: %v", err)
+	}
+	auth := AuthFuncConstantTime("alice", string(hash))
+
+	tests := []struct {
+		user, pass string
+		want       bool
+	}{
+		{"alice", "s3cret", true},
+		{"alice", "wrong", false},
+		{"bob", "s3cret", false},
+		{"bob", "wrong", false},
+	}
+	for _, test := range tests {
+		if got := auth(test.user, test.pass); got != test.want {
+			t.Errorf("auth(%q, %q) = %v, expected %v", test.user, test.pass, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package httpauth
+
+import (
+	"crypto/subtle"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalizeNFC normalises s to Unicode Normalization Form C, as required
+// by RFC 7617 when the UTF-8 charset is advertised, so that the same
+// password typed on clients with different input normalisation compares
+// equal.
+func normalizeNFC(s string) string {
+	return norm.NFC.String(s)
+}
+
+// AuthFuncConstantTime returns an authFunc suitable for Basic that
+// compares the user name in constant time and the password against
+// expectedPassHash (a bcrypt hash, as produced by bcrypt.GenerateFromPassword)
+// using bcrypt's own constant-time comparison. It is a convenient helper
+// for the common case of authenticating against a single, fixed user.
+func AuthFuncConstantTime(expectedUser, expectedPassHash string) func(string, string) bool {
+	return func(user, pass string) bool {
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(expectedUser)) == 1
+		// Always run the bcrypt comparison, even for a wrong user name,
+		// so that a mismatched user name can't be distinguished from a
+		// mismatched password by timing alone.
+		passOK := bcrypt.CompareHashAndPassword([]byte(expectedPassHash), []byte(pass)) == nil
+		return userOK && passOK
+	}
+}
@@ -0,0 +1,135 @@
+package httpauth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// CredentialSource locates candidate credentials for a handler's scheme
+// (e.g. "Basic") within a request, and reports failure in whatever way
+// is appropriate to where it looked.
+type CredentialSource interface {
+	// Extract returns the still-encoded credential candidates found in
+	// r for the given method, e.g. the base64 payloads following
+	// "Basic " in one or more Authorization headers. It returns nil if
+	// none are present.
+	Extract(r *http.Request, method string) []string
+	// Fail is called when no candidate returned by Extract
+	// authenticated successfully, so the source can set whatever
+	// response headers and status code its challenge requires. charset
+	// is the RFC 7617 charset parameter to advertise, or "" for none.
+	Fail(w http.ResponseWriter, realm, method, charset string)
+}
+
+// HeaderSource reads credentials from every value of the Authorization
+// header, and challenges with a 401 and a WWW-Authenticate header. This
+// is the default source used by Basic.
+func HeaderSource() CredentialSource {
+	return headerSource{}
+}
+
+type headerSource struct{}
+
+func (headerSource) Extract(r *http.Request, method string) []string {
+	return extractSchemeValues(r.Header["Authorization"], method)
+}
+
+func (headerSource) Fail(w http.ResponseWriter, realm, method, charset string) {
+	challenge(w, "WWW-Authenticate", http.StatusUnauthorized, realm, method, charset)
+}
+
+// ProxyHeaderSource reads credentials from every value of the
+// Proxy-Authorization header, and challenges with a 407 and a
+// Proxy-Authenticate header, for use behind forward proxies.
+func ProxyHeaderSource() CredentialSource {
+	return proxyHeaderSource{}
+}
+
+type proxyHeaderSource struct{}
+
+func (proxyHeaderSource) Extract(r *http.Request, method string) []string {
+	return extractSchemeValues(r.Header["Proxy-Authorization"], method)
+}
+
+func (proxyHeaderSource) Fail(w http.ResponseWriter, realm, method, charset string) {
+	challenge(w, "Proxy-Authenticate", http.StatusProxyAuthRequired, realm, method, charset)
+}
+
+// CookieSource reads a single credential from the named cookie, whose
+// value is expected to hold the same base64 "user:password" payload
+// that would otherwise follow "Basic " in an Authorization header.
+// Failure is reported the same way as HeaderSource.
+func CookieSource(name string) CredentialSource {
+	return cookieSource{name: name}
+}
+
+type cookieSource struct{ name string }
+
+func (s cookieSource) Extract(r *http.Request, method string) []string {
+	c, err := r.Cookie(s.name)
+	if err != nil || c.Value == "" {
+		return nil
+	}
+	return []string{c.Value}
+}
+
+func (cookieSource) Fail(w http.ResponseWriter, realm, method, charset string) {
+	challenge(w, "WWW-Authenticate", http.StatusUnauthorized, realm, method, charset)
+}
+
+// QuerySource reads a single credential from the named query-string
+// parameter, whose value is expected to hold the same base64
+// "user:password" payload that would otherwise follow "Basic " in an
+// Authorization header. Failure is reported the same way as
+// HeaderSource.
+func QuerySource(name string) CredentialSource {
+	return querySource{name: name}
+}
+
+type querySource struct{ name string }
+
+func (s querySource) Extract(r *http.Request, method string) []string {
+	v := r.URL.Query().Get(s.name)
+	if v == "" {
+		return nil
+	}
+	return []string{v}
+}
+
+func (querySource) Fail(w http.ResponseWriter, realm, method, charset string) {
+	challenge(w, "WWW-Authenticate", http.StatusUnauthorized, realm, method, charset)
+}
+
+// extractSchemeValues strips the "method " prefix off every header
+// value that has one, e.g. turning ["Basic Zm9vOmJhcg=="] into
+// ["Zm9vOmJhcg=="], skipping values belonging to a different scheme.
+// The scheme name is compared in constant time, since it is echoed back
+// verbatim into error branches that an attacker can time.
+func extractSchemeValues(headers []string, method string) []string {
+	var out []string
+	for _, h := range headers {
+		parts := strings.SplitN(h, " ", 2)
+		if len(parts) != 2 || !constantTimeEqual(parts[0], method) {
+			continue
+		}
+		out = append(out, parts[1])
+	}
+	return out
+}
+
+func constantTimeEqual(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func challenge(w http.ResponseWriter, header string, code int, realm, method, charset string) {
+	var attrs []string
+	if charset != "" {
+		attrs = append(attrs, fmt.Sprintf(`charset="%s"`, charset))
+	}
+	writeChallenge(w, header, code, realm, method, attrs...)
+}
@@ -0,0 +1,314 @@
+package httpauth
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DigestAlgorithm selects the hash function a Digest handler uses, per
+// the "algorithm" directive of RFC 7616.
+type DigestAlgorithm string
+
+const (
+	// MD5 is the algorithm required for interoperability with legacy
+	// (RFC 2617) clients.
+	MD5 DigestAlgorithm = "MD5"
+	// SHA256 is the algorithm RFC 7616 recommends for new deployments.
+	SHA256 DigestAlgorithm = "SHA-256"
+)
+
+// DigestOption configures a Digest handler.
+type DigestOption func(*digestHandler)
+
+// WithDigestAlgorithm selects the hash algorithm advertised in the
+// challenge and required of clients. It defaults to SHA256; set it to
+// MD5 to interoperate with clients that only implement RFC 2617.
+func WithDigestAlgorithm(alg DigestAlgorithm) DigestOption {
+	return func(h *digestHandler) { h.algorithm = alg }
+}
+
+// WithNonceTTL overrides how long a server-issued nonce remains valid
+// before a request using it is challenged again with stale=TRUE. The
+// default is five minutes.
+func WithNonceTTL(d time.Duration) DigestOption {
+	return func(h *digestHandler) { h.nonces.ttl = d }
+}
+
+// Digest creates an http.Handler that performs RFC 7616 Digest access
+// authentication, falling back gracefully to RFC 2617 for clients that
+// omit "algorithm". The given realm is passed to clients that attempt to
+// connect without authenticating. secretFn is used to look up, for a
+// user name and realm, HA1 = H(user:realm:password) hex-encoded with
+// the configured algorithm; callers should therefore store that hash
+// rather than a plaintext password. If authentication succeeds, the
+// onSuccess handler is called with the request; if it fails, the client
+// receives a 401 Unauthorized with a fresh challenge.
+func Digest(realm string, onSuccess http.Handler, secretFn func(user, realm string) (ha1 string, ok bool), opts ...DigestOption) http.Handler {
+	h := &digestHandler{
+		baseHandler: baseHandler{method: "Digest", realm: realm, success: onSuccess},
+		secretFn:    secretFn,
+		algorithm:   SHA256,
+		nonces:      newNonceStore(5 * time.Minute),
+		opaque:      randomHex(16),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+type digestHandler struct {
+	baseHandler
+	secretFn  func(user, realm string) (ha1 string, ok bool)
+	algorithm DigestAlgorithm
+	nonces    *nonceStore
+	opaque    string
+}
+
+func (h *digestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	creds := extractSchemeValues(r.Header["Authorization"], "Digest")
+	if len(creds) == 0 {
+		h.challenge(w, false)
+		return
+	}
+	params := parseDigestParams(creds[0])
+
+	if params["realm"] != h.realm {
+		h.challenge(w, false)
+		return
+	}
+
+	algorithm := params["algorithm"]
+	if algorithm == "" {
+		// RFC 2617 clients don't send this directive at all, and mean MD5.
+		algorithm = string(MD5)
+	}
+	if !strings.EqualFold(algorithm, string(h.algorithm)) {
+		h.challenge(w, false)
+		return
+	}
+
+	ha1, ok := h.secretFn(params["username"], h.realm)
+	if !ok {
+		h.challenge(w, false)
+		return
+	}
+
+	// The client's own "uri" directive is what goes into the hash we
+	// check its response against, so it must be tied back to the
+	// request actually being served; otherwise a captured Authorization
+	// header could be replayed against a different URI on the same
+	// connection's auth.
+	if params["uri"] != r.URL.RequestURI() {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	qop := params["qop"]
+	var nc uint64
+	if qop != "" {
+		var err error
+		nc, err = strconv.ParseUint(params["nc"], 16, 64)
+		if err != nil {
+			h.challenge(w, false)
+			return
+		}
+	}
+
+	// RFC 2617's no-qop variant has no nc/cnonce and thus no replay
+	// protection of its own; only enforce nc tracking when qop=auth.
+	switch h.nonces.Check(params["nonce"], qop != "", nc) {
+	case nonceExpired:
+		h.challenge(w, true)
+		return
+	case nonceUnknown, nonceReplayed:
+		h.challenge(w, false)
+		return
+	}
+
+	ha2 := h.hash(r.Method, params["uri"])
+
+	var want string
+	if qop != "" {
+		want = h.hash(ha1, params["nonce"], params["nc"], params["cnonce"], qop, ha2)
+	} else {
+		want = h.hash(ha1, params["nonce"], ha2)
+	}
+
+	if !constantTimeEqual(want, params["response"]) {
+		h.challenge(w, false)
+		return
+	}
+
+	h.success.ServeHTTP(w, r)
+}
+
+// hash joins parts with ":" and hashes them with the handler's
+// configured algorithm, as used throughout RFC 7616 to build HA1, HA2
+// and the final response.
+func (h *digestHandler) hash(parts ...string) string {
+	data := []byte(strings.Join(parts, ":"))
+	if h.algorithm == MD5 {
+		sum := md5.Sum(data)
+		return hex.EncodeToString(sum[:])
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (h *digestHandler) challenge(w http.ResponseWriter, stale bool) {
+	attrs := []string{
+		`qop="auth"`,
+		fmt.Sprintf(`nonce="%s"`, h.nonces.New()),
+		fmt.Sprintf(`opaque="%s"`, h.opaque),
+		fmt.Sprintf("algorithm=%s", h.algorithm),
+	}
+	if stale {
+		attrs = append(attrs, "stale=TRUE")
+	}
+	h.writeChallenge(w, "WWW-Authenticate", http.StatusUnauthorized, attrs...)
+}
+
+// parseDigestParams parses the comma-separated key=value directives of
+// a Digest Authorization header, e.g. `username="x", realm="y", ...`.
+// Values may be quoted or bare, matching the grammar used for qop, nc
+// and algorithm.
+func parseDigestParams(s string) map[string]string {
+	params := make(map[string]string)
+	for _, field := range splitDigestFields(s) {
+		eq := strings.IndexByte(field, '=')
+		if eq < 0 {
+			continue
+		}
+		key := strings.TrimSpace(field[:eq])
+		val := strings.TrimSpace(field[eq+1:])
+		val = strings.Trim(val, `"`)
+		params[key] = val
+	}
+	return params
+}
+
+// splitDigestFields splits on commas that aren't inside a quoted value,
+// since a quoted "uri" directive may itself contain commas.
+func splitDigestFields(s string) []string {
+	var fields []string
+	var cur strings.Builder
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+			cur.WriteByte(c)
+		case c == ',' && !inQuotes:
+			fields = append(fields, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	if cur.Len() > 0 {
+		fields = append(fields, cur.String())
+	}
+	return fields
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic("httpauth: failed to read random bytes: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+type nonceStatus int
+
+const (
+	nonceUnknown nonceStatus = iota
+	nonceExpired
+	nonceReplayed
+	nonceValid
+)
+
+type nonceEntry struct {
+	expires time.Time
+	lastNC  uint64
+}
+
+// maxNonces bounds how many issued nonces a nonceStore retains at once,
+// so that a flood of requests that are never replayed (scanners, a
+// browser's first unauthenticated GET, bad credentials) can't grow the
+// store without bound.
+const maxNonces = 10000
+
+// nonceStore tracks server-issued Digest nonces: when they expire, and
+// the highest nc (client request counter) seen for each, so a replayed
+// nc is rejected.
+type nonceStore struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*nonceEntry
+}
+
+func newNonceStore(ttl time.Duration) *nonceStore {
+	return &nonceStore{ttl: ttl, entries: make(map[string]*nonceEntry)}
+}
+
+func (s *nonceStore) New() string {
+	nonce := randomHex(16)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	if len(s.entries) >= maxNonces {
+		// Simplest possible eviction: drop everything and start over
+		// rather than tracking per-entry recency, same as credCache.
+		s.entries = make(map[string]*nonceEntry)
+	}
+	s.entries[nonce] = &nonceEntry{expires: time.Now().Add(s.ttl)}
+	return nonce
+}
+
+// prune removes expired entries. Callers must hold s.mu.
+func (s *nonceStore) prune() {
+	now := time.Now()
+	for nonce, e := range s.entries {
+		if now.After(e.expires) {
+			delete(s.entries, nonce)
+		}
+	}
+}
+
+// Check reports the status of nonce, and, when trackNC is true (i.e. the
+// request used qop=auth), enforces that nc strictly increases from one
+// request to the next so a captured nc/response pair can't be replayed.
+// RFC 2617's no-qop variant has no nc to track, so trackNC is false
+// there and every request against a live nonce is accepted.
+func (s *nonceStore) Check(nonce string, trackNC bool, nc uint64) nonceStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[nonce]
+	if !ok {
+		return nonceUnknown
+	}
+	if time.Now().After(e.expires) {
+		delete(s.entries, nonce)
+		return nonceExpired
+	}
+	if !trackNC {
+		return nonceValid
+	}
+	if nc <= e.lastNC {
+		return nonceReplayed
+	}
+	e.lastNC = nc
+	return nonceValid
+}
@@ -0,0 +1,260 @@
+package httpauth
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testDigestHash mirrors digestHandler.hash for use by a simulated
+// client building its own Authorization header.
+func testDigestHash(alg DigestAlgorithm, parts ...string) string {
+	data := []byte(strings.Join(parts, ":"))
+	if alg == MD5 {
+		sum := md5.Sum(data)
+		return hex.EncodeToString(sum[:])
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// extractDigestParam pulls a single quoted or bare directive out of a
+// WWW-Authenticate: Digest ... header value.
+func extractDigestParam(header, key string) string {
+	for _, field := range strings.Split(header, ",") {
+		field = strings.TrimSpace(field)
+		field = strings.TrimPrefix(field, "Digest ")
+		if !strings.HasPrefix(field, key+"=") {
+			continue
+		}
+		return strings.Trim(strings.TrimPrefix(field, key+"="), `"`)
+	}
+	return ""
+}
+
+func digestChallenge(t *testing.T, handler http.Handler) (nonce, opaque string) {
+	t.Helper()
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("initial request: code = %v, expected %v", w.Code, http.StatusUnauthorized)
+	}
+	h := w.HeaderMap.Get("WWW-Authenticate")
+	return extractDigestParam(h, "nonce"), extractDigestParam(h, "opaque")
+}
+
+func digestRequest(alg DigestAlgorithm, ha1, method, uri, nonce, nc, cnonce string) *http.Request {
+	ha2 := testDigestHash(alg, method, uri)
+	response := testDigestHash(alg, ha1, nonce, nc, cnonce, "auth", ha2)
+	req, _ := http.NewRequest(method, "http://example.com"+uri, nil)
+	req.Header.Set("Authorization", `Digest username="alice", realm="test-realm", nonce="`+nonce+
+		`", uri="`+uri+`", qop=auth, nc=`+nc+`, cnonce="`+cnonce+`", response="`+response+
+		`", algorithm=`+string(alg))
+	return req
+}
+
+// digestRequestLegacy builds a request in the RFC 2617 style: no qop,
+// nc or cnonce, as sent by clients that predate RFC 7616.
+func digestRequestLegacy(alg DigestAlgorithm, ha1, method, uri, nonce string) *http.Request {
+	ha2 := testDigestHash(alg, method, uri)
+	response := testDigestHash(alg, ha1, nonce, ha2)
+	req, _ := http.NewRequest(method, "http://example.com"+uri, nil)
+	req.Header.Set("Authorization", `Digest username="alice", realm="test-realm", nonce="`+nonce+
+		`", uri="`+uri+`", response="`+response+`"`)
+	return req
+}
+
+func TestDigestSuccessAndReplay(t *testing.T) {
+	secretFn := func(user, realm string) (string, bool) {
+		if user == "alice" && realm == "test-realm" {
+			return testDigestHash(SHA256, "alice", "test-realm", "s3cret"), true
+		}
+		return "", false
+	}
+	handler := Digest("test-realm", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("test-good"))
+	}), secretFn)
+
+	nonce, opaque := digestChallenge(t, handler)
+	if nonce == "" || opaque == "" {
+		t.Fatalf("missing nonce or opaque in challenge")
+	}
+
+	userHA1, _ := secretFn("alice", "test-realm")
+	req := digestRequest(SHA256, userHA1, "GET", "/foo", nonce, "00000001", "clientnonce")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("first use: code = %v, expected %v", w.Code, http.StatusOK)
+	}
+	if w.Body.String() != "test-good" {
+		t.Errorf("response body = %v, expected test-good", w.Body.String())
+	}
+
+	// Replaying the same nc for the same nonce must be rejected.
+	w2 := httptest.NewRecorder()
+	handler.ServeHTTP(w2, req)
+	if w2.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed nc: code = %v, expected %v", w2.Code, http.StatusUnauthorized)
+	}
+	if stale := extractDigestParam(w2.HeaderMap.Get("WWW-Authenticate"), "stale"); stale == "TRUE" {
+		t.Errorf("replay should not be reported as a stale nonce")
+	}
+
+	// A higher nc against the same nonce should succeed.
+	req2 := digestRequest(SHA256, userHA1, "GET", "/foo", nonce, "00000002", "clientnonce2")
+	w3 := httptest.NewRecorder()
+	handler.ServeHTTP(w3, req2)
+	if w3.Code != http.StatusOK {
+		t.Fatalf("second use with higher nc: code = %v, expected %v", w3.Code, http.StatusOK)
+	}
+}
+
+func TestDigestStaleNonce(t *testing.T) {
+	secretFn := func(user, realm string) (string, bool) {
+		return testDigestHash(SHA256, "alice", "test-realm", "s3cret"), true
+	}
+	handler := Digest("test-realm", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("test-good"))
+	}), secretFn, WithNonceTTL(time.Millisecond))
+
+	nonce, _ := digestChallenge(t, handler)
+	time.Sleep(5 * time.Millisecond)
+
+	userHA1, _ := secretFn("alice", "test-realm")
+	req := digestRequest(SHA256, userHA1, "GET", "/foo", nonce, "00000001", "clientnonce")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("code = %v, expected %v", w.Code, http.StatusUnauthorized)
+	}
+	if stale := extractDigestParam(w.HeaderMap.Get("WWW-Authenticate"), "stale"); stale != "TRUE" {
+		t.Errorf("stale = %v, expected TRUE", stale)
+	}
+}
+
+func TestDigestAlgorithmNegotiation(t *testing.T) {
+	secretFn := func(user, realm string) (string, bool) {
+		return testDigestHash(MD5, "alice", "test-realm", "s3cret"), true
+	}
+	handler := Digest("test-realm", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("test-good"))
+	}), secretFn, WithDigestAlgorithm(MD5))
+
+	nonce, _ := digestChallenge(t, handler)
+	userHA1, _ := secretFn("alice", "test-realm")
+
+	// A client using the wrong algorithm should be rejected.
+	wrongAlgReq := digestRequest(SHA256, userHA1, "GET", "/foo", nonce, "00000001", "clientnonce")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, wrongAlgReq)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong algorithm: code = %v, expected %v", w.Code, http.StatusUnauthorized)
+	}
+
+	okReq := digestRequest(MD5, userHA1, "GET", "/foo", nonce, "00000001", "clientnonce")
+	w2 := httptest.NewRecorder()
+	handler.ServeHTTP(w2, okReq)
+	if w2.Code != http.StatusOK {
+		t.Fatalf("MD5: code = %v, expected %v", w2.Code, http.StatusOK)
+	}
+}
+
+// TestDigestLegacyNoQop covers the RFC 2617 fallback: a client that
+// omits qop/nc/cnonce entirely (and so "algorithm" too, which implies
+// MD5). It must be accepted, and repeating the exact same request
+// must also be accepted, since the no-qop variant has no nc to replay.
+func TestDigestLegacyNoQop(t *testing.T) {
+	secretFn := func(user, realm string) (string, bool) {
+		return testDigestHash(MD5, "alice", "test-realm", "s3cret"), true
+	}
+	handler := Digest("test-realm", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("test-good"))
+	}), secretFn, WithDigestAlgorithm(MD5))
+
+	nonce, _ := digestChallenge(t, handler)
+	userHA1, _ := secretFn("alice", "test-realm")
+	req := digestRequestLegacy(MD5, userHA1, "GET", "/foo", nonce)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("first legacy request: code = %v, expected %v", w.Code, http.StatusOK)
+	}
+
+	// No qop means no nc to replay-protect; the same request again is fine.
+	w2 := httptest.NewRecorder()
+	handler.ServeHTTP(w2, req)
+	if w2.Code != http.StatusOK {
+		t.Fatalf("second legacy request: code = %v, expected %v", w2.Code, http.StatusOK)
+	}
+}
+
+func TestDigestURIMismatch(t *testing.T) {
+	secretFn := func(user, realm string) (string, bool) {
+		return testDigestHash(SHA256, "alice", "test-realm", "s3cret"), true
+	}
+	handler := Digest("test-realm", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("test-good"))
+	}), secretFn)
+
+	nonce, _ := digestChallenge(t, handler)
+	userHA1, _ := secretFn("alice", "test-realm")
+
+	// Build a request whose Authorization "uri" directive was computed
+	// for a different path than the one actually being requested.
+	req := digestRequest(SHA256, userHA1, "GET", "/foo", nonce, "00000001", "clientnonce")
+	req.URL.Path = "/bar"
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("code = %v, expected %v", w.Code, http.StatusBadRequest)
+	}
+}
+
+// TestDigestNonceStoreExpiredPruned covers the leak an attacker could
+// otherwise use for memory exhaustion: requests that mint a nonce and
+// are never replayed (unauthenticated GETs, scanners, bad credentials)
+// must not accumulate in nonceStore.entries once their nonce expires.
+func TestDigestNonceStoreExpiredPruned(t *testing.T) {
+	s := newNonceStore(time.Millisecond)
+	for i := 0; i < 100; i++ {
+		s.New()
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// Minting one more nonce should sweep out all of the now-expired
+	// ones, leaving only the fresh one just issued.
+	s.New()
+
+	s.mu.Lock()
+	n := len(s.entries)
+	s.mu.Unlock()
+	if n != 1 {
+		t.Errorf("entries after prune = %d, expected 1", n)
+	}
+}
+
+// TestDigestNonceStoreBounded covers the backstop for long-lived
+// nonces that haven't expired yet: the store must not grow without
+// bound even if every nonce minted is still within its TTL.
+func TestDigestNonceStoreBounded(t *testing.T) {
+	s := newNonceStore(time.Hour)
+	for i := 0; i < maxNonces+10; i++ {
+		s.New()
+	}
+
+	s.mu.Lock()
+	n := len(s.entries)
+	s.mu.Unlock()
+	if n > maxNonces {
+		t.Errorf("entries = %d, expected at most %d", n, maxNonces)
+	}
+}
@@ -0,0 +1,402 @@
+package httpauth
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HashVerifier knows how to check a plaintext password against a hash
+// produced by a particular scheme, such as one of the encodings used by
+// Apache's htpasswd tool.
+type HashVerifier interface {
+	// Match reports whether password, once hashed, matches hash.
+	Match(password, hash string) bool
+	// Prefixes returns the hash prefixes (e.g. "$apr1$", "{SHA}") that
+	// identify a hash as belonging to this scheme. A verifier with no
+	// prefix (the plaintext fallback) returns nil.
+	Prefixes() []string
+}
+
+var (
+	hashVerifiersMu sync.RWMutex
+	hashVerifiers   = map[string]HashVerifier{}
+)
+
+// RegisterHashVerifier makes v available to BasicHtpasswd for every
+// prefix it reports via Prefixes. It is intended to be called from an
+// init function, and is typically used to add support for hash schemes
+// this package does not implement out of the box, such as crypt(3),
+// argon2 or scrypt.
+//
+// RegisterHashVerifier panics if a verifier is already registered for
+// one of v's prefixes.
+func RegisterHashVerifier(v HashVerifier) {
+	hashVerifiersMu.Lock()
+	defer hashVerifiersMu.Unlock()
+	for _, prefix := range v.Prefixes() {
+		if _, dup := hashVerifiers[prefix]; dup {
+			panic(fmt.Sprintf("httpauth: HashVerifier already registered for prefix %q", prefix))
+		}
+		hashVerifiers[prefix] = v
+	}
+}
+
+func init() {
+	RegisterHashVerifier(bcryptVerifier{})
+	RegisterHashVerifier(shaVerifier{})
+	RegisterHashVerifier(apr1Verifier{})
+}
+
+type bcryptVerifier struct{}
+
+func (bcryptVerifier) Prefixes() []string { return []string{"$2a$", "$2b$", "$2y$"} }
+
+func (bcryptVerifier) Match(password, hash string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+type shaVerifier struct{}
+
+func (shaVerifier) Prefixes() []string { return []string{"{SHA}"} }
+
+func (shaVerifier) Match(password, hash string) bool {
+	sum := sha1.Sum([]byte(password))
+	got := base64.StdEncoding.EncodeToString(sum[:])
+	want := strings.TrimPrefix(hash, "{SHA}")
+	return constantTimeEqual(got, want)
+}
+
+type apr1Verifier struct{}
+
+func (apr1Verifier) Prefixes() []string { return []string{"$apr1$"} }
+
+func (apr1Verifier) Match(password, hash string) bool {
+	parts := strings.SplitN(hash, "$", 4)
+	if len(parts) != 4 {
+		return false
+	}
+	salt := parts[2]
+	return constantTimeEqual(apr1Crypt(password, salt), hash)
+}
+
+// apr1Crypt implements Apache's variant of the MD5-crypt algorithm used
+// by htpasswd -m, producing a full "$apr1$salt$hash" string.
+func apr1Crypt(password, salt string) string {
+	h := md5.New()
+	h.Write([]byte(password))
+	h.Write([]byte("$apr1$"))
+	h.Write([]byte(salt))
+
+	h2 := md5.New()
+	h2.Write([]byte(password))
+	h2.Write([]byte(salt))
+	h2.Write([]byte(password))
+	mixin := h2.Sum(nil)
+
+	for i, l := 0, len(password); i < l; i++ {
+		h.Write([]byte{mixin[i%16]})
+	}
+
+	for i := len(password); i != 0; i >>= 1 {
+		if i&1 != 0 {
+			h.Write([]byte{0})
+		} else {
+			h.Write([]byte(password[:1]))
+		}
+	}
+
+	sum := h.Sum(nil)
+	for i := 0; i < 1000; i++ {
+		h3 := md5.New()
+		if i&1 != 0 {
+			h3.Write([]byte(password))
+		} else {
+			h3.Write(sum)
+		}
+		if i%3 != 0 {
+			h3.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			h3.Write([]byte(password))
+		}
+		if i&1 != 0 {
+			h3.Write(sum)
+		} else {
+			h3.Write([]byte(password))
+		}
+		sum = h3.Sum(nil)
+	}
+
+	const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	encode := func(b [3]byte, n int) []byte {
+		var v uint32
+		v = uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+		out := make([]byte, n)
+		for i := 0; i < n; i++ {
+			out[i] = itoa64[v&0x3f]
+			v >>= 6
+		}
+		return out
+	}
+
+	var out []byte
+	out = append(out, encode([3]byte{sum[0], sum[6], sum[12]}, 4)...)
+	out = append(out, encode([3]byte{sum[1], sum[7], sum[13]}, 4)...)
+	out = append(out, encode([3]byte{sum[2], sum[8], sum[14]}, 4)...)
+	out = append(out, encode([3]byte{sum[3], sum[9], sum[15]}, 4)...)
+	out = append(out, encode([3]byte{sum[4], sum[10], sum[5]}, 4)...)
+	out = append(out, encode([3]byte{0, 0, sum[11]}, 2)...)
+
+	return "$apr1$" + salt + "$" + string(out)
+}
+
+type plainVerifier struct{}
+
+func (plainVerifier) Prefixes() []string { return nil }
+
+func (plainVerifier) Match(password, hash string) bool { return password == hash }
+
+// HtpasswdOption configures a BasicHtpasswd authenticator.
+type HtpasswdOption func(*htpasswdAuth)
+
+// WithPollInterval overrides how often the htpasswd file is checked for
+// changes on disk. The default is 10 seconds.
+func WithPollInterval(d time.Duration) HtpasswdOption {
+	return func(a *htpasswdAuth) { a.pollInterval = d }
+}
+
+// WithResultCache enables an in-memory cache of authentication results,
+// keyed by sha256(user|hash|password), so that expensive hash schemes
+// such as bcrypt and apr1 are not recomputed on every request. size is
+// the maximum number of entries to retain; ttl is how long an entry
+// remains valid.
+func WithResultCache(size int, ttl time.Duration) HtpasswdOption {
+	return func(a *htpasswdAuth) { a.cache = newCredCache(size, ttl) }
+}
+
+// AllowPlaintext permits htpasswd entries whose hash is neither bcrypt,
+// {SHA} nor $apr1$ to be compared verbatim. It is off by default because
+// plaintext htpasswd files are rarely intentional.
+func AllowPlaintext() HtpasswdOption {
+	return func(a *htpasswdAuth) { a.allowPlain = true }
+}
+
+type htpasswdAuth struct {
+	path         string
+	pollInterval time.Duration
+	allowPlain   bool
+	cache        *credCache
+	done         chan struct{}
+	stopOnce     sync.Once
+
+	mu    sync.RWMutex
+	users map[string]string
+	mtime time.Time
+}
+
+// htpasswdHandler is the http.Handler returned by BasicHtpasswd. It also
+// implements io.Closer, so callers that tear down or replace a handler
+// (tests, config reload, ...) can stop its background file watcher
+// instead of leaking it for the life of the process.
+type htpasswdHandler struct {
+	http.Handler
+	auth *htpasswdAuth
+}
+
+// Close stops the goroutine that watches the htpasswd file for changes.
+func (h *htpasswdHandler) Close() error {
+	h.auth.stop()
+	return nil
+}
+
+// BasicHtpasswd creates an http.Handler that performs basic
+// authentication against an Apache-style htpasswd file, rather than a
+// caller-supplied authFunc. The file is parsed once up front and then
+// watched for changes; edits are picked up without restarting the
+// process. Each line is "user:hash", with blank lines and lines starting
+// with '#' ignored. Supported hash schemes are bcrypt, {SHA}, $apr1$,
+// and, if AllowPlaintext is given, plaintext; additional schemes can be
+// added with RegisterHashVerifier.
+//
+// The returned handler implements io.Closer; call Close to stop the
+// background watcher once the handler is no longer needed.
+func BasicHtpasswd(realm string, onSuccess http.Handler, path string, opts ...HtpasswdOption) (http.Handler, error) {
+	a := &htpasswdAuth{
+		path:         path,
+		pollInterval: 10 * time.Second,
+		done:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if err := a.reload(); err != nil {
+		return nil, err
+	}
+	go a.watch()
+	return &htpasswdHandler{Handler: Basic(realm, onSuccess, a.authenticate), auth: a}, nil
+}
+
+func (a *htpasswdAuth) reload() error {
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(a.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		users[parts[0]] = parts[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.users = users
+	a.mtime = info.ModTime()
+	a.mu.Unlock()
+	return nil
+}
+
+func (a *htpasswdAuth) watch() {
+	ticker := time.NewTicker(a.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			info, err := os.Stat(a.path)
+			if err != nil {
+				continue
+			}
+			a.mu.RLock()
+			changed := info.ModTime().After(a.mtime)
+			a.mu.RUnlock()
+			if changed {
+				a.reload()
+			}
+		case <-a.done:
+			return
+		}
+	}
+}
+
+// stop terminates the background watch goroutine. It is safe to call
+// more than once.
+func (a *htpasswdAuth) stop() {
+	a.stopOnce.Do(func() { close(a.done) })
+}
+
+func (a *htpasswdAuth) authenticate(user, pass string) bool {
+	a.mu.RLock()
+	hash, ok := a.users[user]
+	a.mu.RUnlock()
+	if !ok {
+		return false
+	}
+
+	var cacheKey string
+	if a.cache != nil {
+		cacheKey = credCacheKey(user, hash, pass)
+		if result, ok := a.cache.Get(cacheKey); ok {
+			return result
+		}
+	}
+
+	result := a.verify(hash, pass)
+	if a.cache != nil {
+		a.cache.Set(cacheKey, result)
+	}
+	return result
+}
+
+func (a *htpasswdAuth) verify(hash, pass string) bool {
+	hashVerifiersMu.RLock()
+	defer hashVerifiersMu.RUnlock()
+	for prefix, v := range hashVerifiers {
+		if strings.HasPrefix(hash, prefix) {
+			return v.Match(pass, hash)
+		}
+	}
+	if a.allowPlain {
+		return plainVerifier{}.Match(pass, hash)
+	}
+	return false
+}
+
+func credCacheKey(user, hash, pass string) string {
+	sum := sha256.Sum256([]byte(user + "|" + hash + "|" + pass))
+	return hex.EncodeToString(sum[:])
+}
+
+type cacheEntry struct {
+	result  bool
+	expires time.Time
+}
+
+// credCache is a small, size-bounded cache of authentication results. It
+// exists to keep per-request latency reasonable in front of expensive
+// hash schemes like bcrypt and apr1.
+type credCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+func newCredCache(size int, ttl time.Duration) *credCache {
+	return &credCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+func (c *credCache) Get(key string) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return false, false
+	}
+	return e.result, true
+}
+
+func (c *credCache) Set(key string, result bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= c.size {
+		// Simplest possible eviction: drop everything and start over
+		// rather than tracking per-entry recency.
+		c.entries = make(map[string]cacheEntry)
+	}
+	c.entries[key] = cacheEntry{
+		result:  result,
+		expires: time.Now().Add(c.ttl),
+	}
+}
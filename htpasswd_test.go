@@ -0,0 +1,109 @@
+package httpauth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func writeHtpasswd(t *testing.T, contents string) string {
+	f, err := os.CreateTemp("", "httpauth-htpasswd")
+	if err != nil {
+		t.Fatalf("failed to create temp htpasswd file: %v", err)
+	}
+	if _, err := f.WriteString(contents); err != nil {
+		t.Fatalf("failed to write temp htpasswd file: %v", err)
+	}
+	f.Close()
+	return f.Name()
+}
+
+func TestBasicHtpasswd(t *testing.T) {
+	// bcrypt hash of "nothing", sha hash of "swordfish", apr1 hash of
+	// "wobble" generated with `htpasswd -m`/`htpasswd -s`/`htpasswd -B`.
+	path := writeHtpasswd(t, `
+# comment and blank lines should be ignored
+
+test:$2a$10$jU913GhOG74q.cJ7q37DT..D827Pqh9b35LDCWcwkT6X9rvo43tvW
+sha:{SHA}T1cYHcqt6YBVXyzmdVykJfAGWL4=
+apr1:$apr1$RQ3nvzqR$wpsyKiWAvrwGL1QBN2eMw1
+`)
+	defer os.Remove(path)
+
+	handler, err := BasicHtpasswd("test-realm", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("test-good"))
+	}), path, WithResultCache(16, time.Minute))
+	if err != nil {
+		t.Fatalf("BasicHtpasswd: %v", err)
+	}
+	defer handler.(io.Closer).Close()
+
+	tests := []struct {
+		user, pass string
+		ok         bool
+	}{
+		{"test", "nothing", true},
+		{"test", "wrong", false},
+		{"sha", "swordfish", true},
+		{"sha", "wrong", false},
+		{"apr1", "wobble", true},
+		{"apr1", "wrong", false},
+		{"nosuchuser", "whatever", false},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", "http://example.com/foo", nil)
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		req.SetBasicAuth(test.user, test.pass)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		wantCode := http.StatusUnauthorized
+		if test.ok {
+			wantCode = http.StatusOK
+		}
+		if w.Code != wantCode {
+			t.Errorf("user=%q pass=%q: code = %v, expected %v", test.user, test.pass, w.Code, wantCode)
+		}
+
+		// Run each case twice to exercise the result cache.
+		w2 := httptest.NewRecorder()
+		handler.ServeHTTP(w2, req)
+		if w2.Code != wantCode {
+			t.Errorf("user=%q pass=%q (cached): code = %v, expected %v", test.user, test.pass, w2.Code, wantCode)
+		}
+	}
+}
+
+func TestBasicHtpasswdMissingFile(t *testing.T) {
+	_, err := BasicHtpasswd("test-realm", nil, "/no/such/file")
+	if err == nil {
+		t.Fatal("expected an error for a missing htpasswd file, got nil")
+	}
+}
+
+func TestBasicHtpasswdClose(t *testing.T) {
+	path := writeHtpasswd(t, "test:$2a$10$jU913GhOG74q.cJ7q37DT..D827Pqh9b35LDCWcwkT6X9rvo43tvW\n")
+	defer os.Remove(path)
+
+	handler, err := BasicHtpasswd("test-realm", http.NotFoundHandler(), path, WithPollInterval(time.Millisecond))
+	if err != nil {
+		t.Fatalf("BasicHtpasswd: %v", err)
+	}
+	closer, ok := handler.(io.Closer)
+	if !ok {
+		t.Fatal("handler returned by BasicHtpasswd does not implement io.Closer")
+	}
+	// Closing must stop the watcher goroutine and be safe to call twice.
+	if err := closer.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if err := closer.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}